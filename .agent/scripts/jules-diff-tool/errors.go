@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// errline wraps err with the file:line of its caller, in the spirit of
+// goredo's ErrLine, so every failure that ends up in error.json/ERROR.md
+// points back at the call site that produced it rather than just the
+// bottom-most message in the chain.
+func errline(err error) error {
+	if err == nil {
+		return nil
+	}
+	_, file, line, ok := runtime.Caller(1)
+	if !ok {
+		return err
+	}
+	return &locatedError{loc: fmt.Sprintf("%s:%d", filepath.Base(file), line), err: err}
+}
+
+type locatedError struct {
+	loc string
+	err error
+}
+
+func (e *locatedError) Error() string { return fmt.Sprintf("%s: %s", e.loc, e.err) }
+func (e *locatedError) Unwrap() error { return e.err }
+
+// commandError is the shared shape behind ListError, PullError, ParseError
+// and WriteError: the failing command (nil for pure filesystem failures),
+// its exit code, a tail of the output captured before it failed, and how
+// long it ran. Keeping this structured, instead of a bare err.Error(), is
+// what lets extractSession render a useful error.json/ERROR.md per session.
+type commandError struct {
+	Command    []string
+	ExitCode   int
+	OutputTail string
+	Duration   time.Duration
+	cause      error
+}
+
+// newCommandError builds a commandError from a command invocation's raw
+// output and the error runCommandContext returned for it.
+func newCommandError(command []string, output []byte, dur time.Duration, cause error) commandError {
+	ce := commandError{Command: command, Duration: dur, cause: cause, OutputTail: tail(string(output), 20)}
+	var exitErr *exec.ExitError
+	if errors.As(cause, &exitErr) {
+		ce.ExitCode = exitErr.ExitCode()
+	}
+	return ce
+}
+
+func (e commandError) Error() string {
+	if len(e.Command) == 0 {
+		return e.cause.Error()
+	}
+	return fmt.Sprintf("%s (exit %d): %s", strings.Join(e.Command, " "), e.ExitCode, e.cause)
+}
+
+func (e commandError) Unwrap() error { return e.cause }
+
+// tail returns the last n lines of s, for trimming a command's captured
+// output down to something worth keeping in error.json.
+func tail(s string, n int) string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ListError wraps a failure to list sessions at all, via any spelling of
+// the `jules remote list` JSON flag or the textual fallback. It halts the
+// whole run: with no session list, nothing can be extracted.
+type ListError struct{ commandError }
+
+func newListError(command []string, output []byte, dur time.Duration, cause error) *ListError {
+	return &ListError{newCommandError(command, output, dur, cause)}
+}
+
+// PullError wraps a failure of `jules remote pull` for one session.
+type PullError struct{ commandError }
+
+func newPullError(command []string, output []byte, dur time.Duration, cause error) *PullError {
+	return &PullError{newCommandError(command, output, dur, cause)}
+}
+
+// ParseError wraps a command that exited zero but whose output didn't
+// match the shape we expected from it (e.g. `--output json` accepted but
+// printing something that isn't a []Session).
+type ParseError struct{ commandError }
+
+func newParseError(command []string, output []byte, dur time.Duration, cause error) *ParseError {
+	return &ParseError{newCommandError(command, output, dur, cause)}
+}
+
+// WriteError wraps a failure to persist extracted output locally (creating
+// a session directory, content-addressing a diff, writing manifest or
+// metadata files). There's no subprocess behind these, so Command,
+// ExitCode and OutputTail are left zero.
+type WriteError struct{ commandError }
+
+func newWriteError(cause error) *WriteError {
+	return &WriteError{commandError{cause: cause}}
+}
+
+// classify unwraps err looking for one of the four typed errors above,
+// returning its kind name and shared fields for rendering.
+func classify(err error) (kind string, ce commandError, ok bool) {
+	var le *ListError
+	if errors.As(err, &le) {
+		return "ListError", le.commandError, true
+	}
+	var pe *PullError
+	if errors.As(err, &pe) {
+		return "PullError", pe.commandError, true
+	}
+	var pae *ParseError
+	if errors.As(err, &pae) {
+		return "ParseError", pae.commandError, true
+	}
+	var we *WriteError
+	if errors.As(err, &we) {
+		return "WriteError", we.commandError, true
+	}
+	return "", commandError{}, false
+}
+
+// remediation suggests a next step for ERROR.md, tailored to the error
+// kind and what's known about the session.
+func remediation(kind string, ce commandError, session Session) string {
+	switch kind {
+	case "ListError":
+		return "could not list sessions at all -- check that `jules` is on PATH and authenticated (`jules auth status`)"
+	case "PullError":
+		if strings.Contains(session.Status, "Fail") {
+			return fmt.Sprintf("session status was %q -- try `jules remote resume --session %s`", session.Status, session.ID)
+		}
+		return "pull failed -- rerun with -jobs 1 to isolate it, or retry once the session settles"
+	case "ParseError":
+		return "output didn't match the shape we expected -- this `jules` version may have changed its output format"
+	case "WriteError":
+		return "local filesystem write failed -- check disk space and permissions under the output directory"
+	default:
+		return "no specific remediation known for this error"
+	}
+}
+
+// errorRecord is the JSON shape written to both a session's error.json and
+// the run's aggregated top-level errors.json.
+type errorRecord struct {
+	SessionID string   `json:"session_id"`
+	Kind      string   `json:"kind"`
+	Command   []string `json:"command,omitempty"`
+	ExitCode  int      `json:"exit_code,omitempty"`
+	Duration  string   `json:"duration,omitempty"`
+	Output    string   `json:"output_tail,omitempty"`
+	Error     string   `json:"error"`
+}
+
+func newErrorRecord(sessionID string, err error) errorRecord {
+	rec := errorRecord{SessionID: sessionID, Error: err.Error(), Kind: "Error"}
+	kind, ce, ok := classify(err)
+	if !ok {
+		return rec
+	}
+	rec.Kind = kind
+	rec.Command = ce.Command
+	rec.ExitCode = ce.ExitCode
+	rec.Output = ce.OutputTail
+	if ce.Duration > 0 {
+		rec.Duration = ce.Duration.Round(time.Millisecond).String()
+	}
+	return rec
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SelectFunc reports whether path (the "a/" side of a diff hunk) should be
+// kept in the extracted output for session.
+type SelectFunc func(path string, session Session) bool
+
+// pattern is a single gitignore-style glob, optionally negated with a
+// leading "!".
+type pattern struct {
+	glob   string
+	negate bool
+}
+
+// SelectFilter composes include/exclude globs and an optional path regex
+// into a single SelectFunc, mirroring the include/exclude pipeline restic's
+// archiver.Archiver runs over each file it archives.
+type SelectFilter struct {
+	includes  []pattern
+	excludes  []pattern
+	pathRegex *regexp.Regexp
+}
+
+// NewSelectFilter builds a SelectFilter from raw --include/--exclude values,
+// the contents of any --exclude-file, and an optional --path-regex.
+func NewSelectFilter(includes, excludes []string, excludeFile, pathRegex string) (*SelectFilter, error) {
+	f := &SelectFilter{
+		includes: parsePatterns(includes),
+		excludes: parsePatterns(excludes),
+	}
+
+	if excludeFile != "" {
+		lines, err := readPatternFile(excludeFile)
+		if err != nil {
+			return nil, err
+		}
+		f.excludes = append(f.excludes, parsePatterns(lines)...)
+	}
+
+	if pathRegex != "" {
+		re, err := regexp.Compile(pathRegex)
+		if err != nil {
+			return nil, err
+		}
+		f.pathRegex = re
+	}
+
+	return f, nil
+}
+
+func parsePatterns(raw []string) []pattern {
+	var out []pattern
+	for _, r := range raw {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		p := pattern{glob: r}
+		if strings.HasPrefix(r, "!") {
+			p.negate = true
+			p.glob = r[1:]
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func readPatternFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// Select implements SelectFunc. Includes are evaluated first: with no
+// include patterns every path starts selected, otherwise a path must match
+// at least one include glob. Excludes are then evaluated in order, later
+// patterns overriding earlier ones, and a "!"-prefixed exclude pattern
+// re-selects a path it matches (gitignore negation semantics). Finally, if
+// --path-regex was given, the path must also match it.
+func (f *SelectFilter) Select(path string, session Session) bool {
+	selected := len(f.includes) == 0
+	for _, p := range f.includes {
+		if matchGlob(p.glob, path) {
+			selected = true
+		}
+	}
+
+	for _, p := range f.excludes {
+		if matchGlob(p.glob, path) {
+			selected = p.negate
+		}
+	}
+
+	if selected && f.pathRegex != nil {
+		selected = f.pathRegex.MatchString(path)
+	}
+
+	return selected
+}
+
+// matchGlob reports whether glob matches path, supporting "**" as a
+// path-spanning wildcard in addition to filepath.Match's single-segment
+// "*", "?" and "[...]" patterns.
+func matchGlob(glob, path string) bool {
+	if !strings.Contains(glob, "**") {
+		ok, err := filepath.Match(glob, path)
+		return err == nil && ok
+	}
+
+	re, err := globToRegexp(glob)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(path)
+}
+
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	i := 0
+	for i < len(glob) {
+		switch {
+		// A "**/" (leading, e.g. "**/*.go", or interior, e.g. "a/**/b")
+		// means "zero or more directories" in gitignore semantics, so it
+		// must not force a literal "/" to be present: emit an optional
+		// group rather than ".*" followed by a mandatory separator.
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			i++
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// diffHunk is a single "diff --git a/... b/..." section of a unified diff,
+// kept verbatim so filtered diffs remain byte-identical to the source for
+// surviving files.
+type diffHunk struct {
+	path string // the "a/" path, used for filtering
+	text string // full hunk text, including the "diff --git" header line
+}
+
+// splitDiffHunks parses a unified diff produced by `jules remote pull` into
+// its per-file hunks.
+func splitDiffHunks(diff string) []diffHunk {
+	re := regexp.MustCompile(`(?m)^diff --git a/(\S+) b/\S+`)
+	matches := re.FindAllStringSubmatchIndex(diff, -1)
+	if matches == nil {
+		return nil
+	}
+
+	var hunks []diffHunk
+	for i, m := range matches {
+		start := m[0]
+		end := len(diff)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		hunks = append(hunks, diffHunk{
+			path: diff[m[2]:m[3]],
+			text: diff[start:end],
+		})
+	}
+	return hunks
+}
+
+// filterDiff applies sel to every hunk in diff and reassembles a diff
+// containing only the surviving hunks, so SUMMARY.md counts and the files
+// actually written to disk stay in sync.
+func filterDiff(diff string, session Session, sel SelectFunc) string {
+	if sel == nil {
+		return diff
+	}
+
+	hunks := splitDiffHunks(diff)
+	if hunks == nil {
+		return diff
+	}
+
+	var b strings.Builder
+	for _, h := range hunks {
+		if sel(h.path, session) {
+			b.WriteString(h.text)
+		}
+	}
+	return b.String()
+}
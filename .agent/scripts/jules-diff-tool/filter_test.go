@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "src/main.go", true},
+		{"**/*.go", "src/pkg/main.go", true},
+		{"**/*.go", "main.txt", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/c", false},
+		{"*.go", "main.go", true},
+		{"*.go", "src/main.go", false},
+		{"src/*", "src/main.go", true},
+		{"src/*", "src/pkg/main.go", false},
+	}
+
+	for _, c := range cases {
+		if got := matchGlob(c.glob, c.path); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", c.glob, c.path, got, c.want)
+		}
+	}
+}
+
+func TestSelectFilterSelect(t *testing.T) {
+	session := Session{ID: "s1"}
+
+	t.Run("no patterns selects everything", func(t *testing.T) {
+		f, err := NewSelectFilter(nil, nil, "", "")
+		if err != nil {
+			t.Fatalf("NewSelectFilter: %v", err)
+		}
+		if !f.Select("anything.go", session) {
+			t.Error("expected path to be selected with no patterns")
+		}
+	})
+
+	t.Run("include restricts to matching paths", func(t *testing.T) {
+		f, err := NewSelectFilter([]string{"**/*.go"}, nil, "", "")
+		if err != nil {
+			t.Fatalf("NewSelectFilter: %v", err)
+		}
+		if !f.Select("main.go", session) {
+			t.Error("expected main.go to be included")
+		}
+		if f.Select("main.txt", session) {
+			t.Error("expected main.txt to be excluded")
+		}
+	})
+
+	t.Run("exclude removes matching paths", func(t *testing.T) {
+		f, err := NewSelectFilter(nil, []string{"**/*_test.go"}, "", "")
+		if err != nil {
+			t.Fatalf("NewSelectFilter: %v", err)
+		}
+		if f.Select("main_test.go", session) {
+			t.Error("expected main_test.go to be excluded")
+		}
+		if !f.Select("main.go", session) {
+			t.Error("expected main.go to remain included")
+		}
+	})
+
+	t.Run("negated exclude re-includes", func(t *testing.T) {
+		f, err := NewSelectFilter(nil, []string{"**/*.go", "!main.go"}, "", "")
+		if err != nil {
+			t.Fatalf("NewSelectFilter: %v", err)
+		}
+		if f.Select("other.go", session) {
+			t.Error("expected other.go to stay excluded")
+		}
+		if !f.Select("main.go", session) {
+			t.Error("expected main.go to be re-included by negation")
+		}
+	})
+
+	t.Run("path regex further restricts", func(t *testing.T) {
+		f, err := NewSelectFilter(nil, nil, "", `^src/`)
+		if err != nil {
+			t.Fatalf("NewSelectFilter: %v", err)
+		}
+		if !f.Select("src/main.go", session) {
+			t.Error("expected src/main.go to match path regex")
+		}
+		if f.Select("other/main.go", session) {
+			t.Error("expected other/main.go to be rejected by path regex")
+		}
+	})
+}
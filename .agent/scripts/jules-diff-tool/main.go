@@ -1,13 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 )
@@ -29,22 +30,67 @@ type SessionMetadata struct {
 	FilesCount  int       `json:"files_count"`
 }
 
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error {
+	*l = append(*l, strings.Split(v, ",")...)
+	return nil
+}
+
 func main() {
 	outputDir := flag.String("output", ".agent/reports/jules_diffs", "Output directory for diffs")
 	statusFilter := flag.String("status", "", "Filter by status (comma-separated: Completed,Paused,Awaiting)")
 	all := flag.Bool("all", false, "Extract from all sessions regardless of status")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of sessions to extract concurrently")
+	dryRun := flag.Bool("dry-run", false, "List what would be extracted without pulling any diffs")
+	runGC := flag.Bool("gc", false, "Prune objects/ of anything no manifest.json references, then exit")
+	showStats := flag.Bool("stats", false, "Report logical vs physical bytes stored by the content-addressed store, then exit")
+
+	var includes, excludes stringList
+	flag.Var(&includes, "include", "Gitignore-style glob to include (repeatable, comma-separated); e.g. **/*.go")
+	flag.Var(&excludes, "exclude", "Gitignore-style glob to exclude (repeatable, comma-separated); prefix with ! to re-include")
+	excludeFile := flag.String("exclude-file", "", "File of gitignore-style exclude patterns, one per line")
+	pathRegex := flag.String("path-regex", "", "Full regex a file's path must match to be kept")
 	flag.Parse()
 
-	date := time.Now().Format("2006-01-02")
-	baseDir := filepath.Join(*outputDir, date)
+	if *runGC {
+		removed, freed, err := gc(*outputDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running gc: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("gc: removed %d unreferenced object(s), freed %s\n", removed, humanSize(freed))
+		return
+	}
 
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+	if *showStats {
+		logical, physical, err := stats(*outputDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error computing stats: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("logical:  %s\n", humanSize(logical))
+		fmt.Printf("physical: %s\n", humanSize(physical))
+		fmt.Printf("saved:    %s\n", humanSize(logical-physical))
+		return
+	}
+
+	sel, err := NewSelectFilter(includes, excludes, *excludeFile, *pathRegex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building select filter: %v\n", err)
 		os.Exit(1)
 	}
 
+	date := time.Now().Format("2006-01-02")
+	baseDir := filepath.Join(*outputDir, date)
+
+	ctx, stop := installCancelOnSignal(context.Background())
+	defer stop()
+
 	// Get session list
-	sessions, err := listSessions()
+	var source SessionSource = commandSessionSource{}
+	sessions, err := source.List(ctx)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error listing sessions: %v\n", err)
 		os.Exit(1)
@@ -52,7 +98,49 @@ func main() {
 
 	// Filter sessions
 	filtered := filterSessions(sessions, *all, *statusFilter)
-	fmt.Printf("Found %d sessions to extract (total: %d)\n", len(filtered), len(sessions))
+	fmt.Printf("Found %d sessions to extract (total: %d) using %d workers\n", len(filtered), len(sessions), *jobs)
+
+	state, err := loadState(*outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	var toExtract, skipped []Session
+	for _, s := range filtered {
+		if _, ok := state.unchanged(s); ok {
+			skipped = append(skipped, s)
+		} else {
+			toExtract = append(toExtract, s)
+		}
+	}
+
+	if *dryRun {
+		for _, s := range toExtract {
+			fmt.Printf("  would extract %s (%s): %s\n", s.ID, s.Status, s.Description)
+		}
+		for _, s := range skipped {
+			fmt.Printf("  would skip %s (unchanged since last run)\n", s.ID)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	store := newObjectStore(*outputDir)
+	results := runExtraction(ctx, toExtract, baseDir, *jobs, sel, store)
+
+	for _, r := range results {
+		if r.err == nil && !r.interrupted {
+			state.Sessions[r.session.ID] = StateEntry{LastActive: r.session.LastActive, FilesCount: r.filesCount}
+		}
+	}
+	if err := state.save(*outputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
+	}
 
 	// Create summary file
 	summaryPath := filepath.Join(baseDir, "SUMMARY.md")
@@ -67,67 +155,110 @@ func main() {
 	fmt.Fprintf(summaryFile, "| Session ID | Task | Status | Files |\n")
 	fmt.Fprintf(summaryFile, "|------------|------|--------|-------|\n")
 
-	successCount := 0
-	for i, session := range filtered {
-		fmt.Printf("[%d/%d] Extracting %s (%s)...\n", i+1, len(filtered), session.ID, session.Status)
-
-		sessionDir := filepath.Join(baseDir, session.ID)
-		if err := os.MkdirAll(sessionDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "  Error creating dir: %v\n", err)
-			continue
+	successCount, interruptedCount := 0, 0
+	var errRecords []errorRecord
+	for _, r := range results {
+		switch {
+		case r.interrupted:
+			fmt.Fprintf(summaryFile, "| %s | %s | %s | INTERRUPTED |\n", r.session.ID, truncate(r.session.Description, 50), r.session.Status)
+			interruptedCount++
+		case r.err != nil:
+			cell := "ERROR"
+			if _, statErr := os.Stat(filepath.Join(baseDir, r.session.ID, "ERROR.md")); statErr == nil {
+				cell = fmt.Sprintf("[ERROR](%s/ERROR.md)", r.session.ID)
+			}
+			fmt.Fprintf(summaryFile, "| %s | %s | %s | %s |\n", r.session.ID, truncate(r.session.Description, 50), r.session.Status, cell)
+			errRecords = append(errRecords, newErrorRecord(r.session.ID, r.err))
+		default:
+			fmt.Fprintf(summaryFile, "| %s | %s | %s | %d |\n", r.session.ID, truncate(r.session.Description, 50), r.session.Status, r.filesCount)
+			successCount++
 		}
+	}
+	for _, s := range skipped {
+		entry, _ := state.unchanged(s)
+		fmt.Fprintf(summaryFile, "| %s | %s | %s | %d (skipped, unchanged) |\n", s.ID, truncate(s.Description, 50), s.Status, entry.FilesCount)
+	}
 
-		// Pull diff
-		diff, err := pullDiff(session.ID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  Error pulling diff: %v\n", err)
-			writeErrorFile(sessionDir, err)
-			fmt.Fprintf(summaryFile, "| %s | %s | %s | ERROR |\n", session.ID, truncate(session.Description, 50), session.Status)
-			continue
+	if len(errRecords) > 0 {
+		if data, err := json.MarshalIndent(errRecords, "", "  "); err == nil {
+			os.WriteFile(filepath.Join(baseDir, "errors.json"), data, 0644)
 		}
+	}
 
-		// Write diff file
-		diffPath := filepath.Join(sessionDir, "changes.diff")
-		if err := os.WriteFile(diffPath, []byte(diff), 0644); err != nil {
-			fmt.Fprintf(os.Stderr, "  Error writing diff: %v\n", err)
-			continue
-		}
+	fmt.Printf("\nDone! Extracted %d/%d sessions (%d skipped, unchanged) to %s\n", successCount, len(toExtract), len(skipped), baseDir)
+	fmt.Printf("Summary: %s\n", summaryPath)
+
+	switch {
+	case interruptedCount > 0:
+		fmt.Fprintf(os.Stderr, "Extraction interrupted: %d session(s) left incomplete\n", interruptedCount)
+		os.Exit(1)
+	case len(errRecords) > 0 && successCount == 0:
+		fmt.Fprintf(os.Stderr, "Extraction failed: all %d attempted session(s) errored; see %s\n", len(errRecords), filepath.Join(baseDir, "errors.json"))
+		os.Exit(1)
+	case len(errRecords) > 0:
+		fmt.Fprintf(os.Stderr, "Extraction partially failed: %d/%d session(s) errored; see %s\n", len(errRecords), len(toExtract), filepath.Join(baseDir, "errors.json"))
+		os.Exit(2)
+	}
+}
+
+// extractSession pulls a single session's diff and writes its outputs
+// (manifest.json, changes.diff, files_changed.txt, metadata.json) under
+// baseDir, content-addressing the diff and its hunks into store so
+// identical content across sessions is only written once. It is called
+// concurrently by runExtraction's worker pool, so it must not touch any
+// shared state besides the filesystem.
+func extractSession(ctx context.Context, session Session, baseDir string, sel *SelectFilter, store *objectStore) (int, error) {
+	sessionDir := filepath.Join(baseDir, session.ID)
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		return 0, errline(newWriteError(fmt.Errorf("creating dir: %w", err)))
+	}
 
-		// Extract file list
-		files := extractFileList(diff)
-		filesPath := filepath.Join(sessionDir, "files_changed.txt")
-		os.WriteFile(filesPath, []byte(strings.Join(files, "\n")), 0644)
-
-		// Write metadata
-		meta := SessionMetadata{
-			SessionID:   session.ID,
-			TaskName:    session.Description,
-			Status:      session.Status,
-			LastActive:  session.LastActive,
-			ExtractedAt: time.Now(),
-			FilesCount:  len(files),
+	diff, err := pullDiff(ctx, session.ID)
+	if err != nil {
+		// A cancelled ctx is reported as INTERRUPTED in SUMMARY.md, not as
+		// a failure of this particular command; don't leave a spurious
+		// error.json/ERROR.md behind claiming otherwise.
+		if ctx.Err() == nil {
+			writeErrorOutputs(sessionDir, session, err)
 		}
-		metaBytes, _ := json.MarshalIndent(meta, "", "  ")
-		metaPath := filepath.Join(sessionDir, "metadata.json")
-		os.WriteFile(metaPath, metaBytes, 0644)
+		return 0, err
+	}
 
-		fmt.Fprintf(summaryFile, "| %s | %s | %s | %d |\n", session.ID, truncate(session.Description, 50), session.Status, len(files))
-		successCount++
-		fmt.Printf("  ✓ Extracted %d files\n", len(files))
+	diff = filterDiff(diff, session, sel.Select)
+
+	if _, _, _, err := store.storeSession(sessionDir, diff); err != nil {
+		werr := errline(newWriteError(fmt.Errorf("storing diff: %w", err)))
+		if ctx.Err() == nil {
+			writeErrorOutputs(sessionDir, session, werr)
+		}
+		return 0, werr
 	}
 
-	fmt.Printf("\nDone! Extracted %d/%d sessions to %s\n", successCount, len(filtered), baseDir)
-	fmt.Printf("Summary: %s\n", summaryPath)
-}
+	files := extractFileList(diff)
+	filesPath := filepath.Join(sessionDir, "files_changed.txt")
+	if err := os.WriteFile(filesPath, []byte(strings.Join(files, "\n")), 0644); err != nil {
+		werr := errline(newWriteError(fmt.Errorf("writing files_changed.txt: %w", err)))
+		writeErrorOutputs(sessionDir, session, werr)
+		return 0, werr
+	}
 
-func listSessions() ([]Session, error) {
-	cmd := exec.Command("jules", "remote", "list", "--session")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("jules list failed: %w\nOutput: %s", err, string(output))
+	meta := SessionMetadata{
+		SessionID:   session.ID,
+		TaskName:    session.Description,
+		Status:      session.Status,
+		LastActive:  session.LastActive,
+		ExtractedAt: time.Now(),
+		FilesCount:  len(files),
+	}
+	metaBytes, _ := json.MarshalIndent(meta, "", "  ")
+	metaPath := filepath.Join(sessionDir, "metadata.json")
+	if err := os.WriteFile(metaPath, metaBytes, 0644); err != nil {
+		werr := errline(newWriteError(fmt.Errorf("writing metadata.json: %w", err)))
+		writeErrorOutputs(sessionDir, session, werr)
+		return 0, werr
 	}
 
-	return parseSessionList(string(output)), nil
+	return len(files), nil
 }
 
 func parseSessionList(output string) []Session {
@@ -181,11 +312,12 @@ func filterSessions(sessions []Session, all bool, statusFilter string) []Session
 	return filtered
 }
 
-func pullDiff(sessionID string) (string, error) {
-	cmd := exec.Command("jules", "remote", "pull", "--session", sessionID)
-	output, err := cmd.CombinedOutput()
+func pullDiff(ctx context.Context, sessionID string) (string, error) {
+	cmd := []string{"jules", "remote", "pull", "--session", sessionID}
+	start := time.Now()
+	output, err := runCommandContext(ctx, cmd[0], cmd[1:]...)
 	if err != nil {
-		return "", fmt.Errorf("pull failed: %w", err)
+		return "", errline(newPullError(cmd, output, time.Since(start), err))
 	}
 	return string(output), nil
 }
@@ -205,9 +337,39 @@ func extractFileList(diff string) []string {
 	return files
 }
 
-func writeErrorFile(dir string, err error) {
-	errPath := filepath.Join(dir, "ERROR.txt")
-	os.WriteFile(errPath, []byte(err.Error()), 0644)
+// writeErrorOutputs renders a failed session's error as a machine-parseable
+// error.json and a human-readable ERROR.md (command, output tail, and a
+// suggested remediation) in place of a bare err.Error() dump, and returns
+// the record so the caller can also fold it into the run's errors.json.
+func writeErrorOutputs(dir string, session Session, err error) errorRecord {
+	rec := newErrorRecord(session.ID, err)
+
+	if data, jerr := json.MarshalIndent(rec, "", "  "); jerr == nil {
+		os.WriteFile(filepath.Join(dir, "error.json"), data, 0644)
+	}
+
+	kind, ce, _ := classify(err)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Extraction failed: %s\n\n", session.ID)
+	fmt.Fprintf(&b, "- kind: %s\n", rec.Kind)
+	if len(ce.Command) > 0 {
+		fmt.Fprintf(&b, "- command: `%s`\n", strings.Join(ce.Command, " "))
+	}
+	if ce.ExitCode != 0 {
+		fmt.Fprintf(&b, "- exit code: %d\n", ce.ExitCode)
+	}
+	if rec.Duration != "" {
+		fmt.Fprintf(&b, "- duration: %s\n", rec.Duration)
+	}
+	fmt.Fprintf(&b, "\n%s\n", err)
+	if ce.OutputTail != "" {
+		fmt.Fprintf(&b, "\n## Output tail\n\n```\n%s\n```\n", ce.OutputTail)
+	}
+	fmt.Fprintf(&b, "\n## Suggested remediation\n\n%s\n", remediation(kind, ce, session))
+
+	os.WriteFile(filepath.Join(dir, "ERROR.md"), []byte(b.String()), 0644)
+	return rec
 }
 
 func truncate(s string, max int) string {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SessionSource lists the sessions available for extraction. It exists so
+// tests can inject fixtures without shelling out to the real `jules` CLI.
+type SessionSource interface {
+	List(ctx context.Context) ([]Session, error)
+}
+
+// commandSessionSource shells out to `jules remote list`, preferring its
+// structured JSON output and falling back to the textual column parser only
+// if the installed `jules` doesn't support a JSON flag.
+type commandSessionSource struct{}
+
+func (commandSessionSource) List(ctx context.Context) ([]Session, error) {
+	return listSessions(ctx)
+}
+
+// jsonFlagsToTry are attempted in order; `jules` versions have shipped both
+// spellings of the flag at different times.
+var jsonFlagsToTry = []string{"--output", "--format"}
+
+func listSessions(ctx context.Context) ([]Session, error) {
+	for _, flag := range jsonFlagsToTry {
+		sessions, ok, err := listSessionsJSON(ctx, flag)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return sessions, nil
+		}
+	}
+
+	cmd := []string{"jules", "remote", "list", "--session"}
+	start := time.Now()
+	output, err := runCommandContext(ctx, cmd[0], cmd[1:]...)
+	if err != nil {
+		return nil, errline(newListError(cmd, output, time.Since(start), err))
+	}
+	return parseSessionList(string(output)), nil
+}
+
+// listSessionsJSON runs `jules remote list --session <flag> json` and
+// unmarshals its output directly into []Session. ok is false (with a nil
+// error) when the flag itself isn't supported by this jules version, so the
+// caller can fall back to the next flag spelling or the textual parser.
+func listSessionsJSON(ctx context.Context, flag string) ([]Session, bool, error) {
+	cmd := []string{"jules", "remote", "list", "--session", flag, "json"}
+	start := time.Now()
+	output, err := runCommandContext(ctx, cmd[0], cmd[1:]...)
+	dur := time.Since(start)
+	if err != nil {
+		if flagUnsupported(string(output), err) {
+			return nil, false, nil
+		}
+		return nil, false, errline(newListError(cmd, output, dur, err))
+	}
+
+	var sessions []Session
+	if err := json.Unmarshal(output, &sessions); err != nil {
+		if !looksLikeJSON(output) {
+			// Output didn't parse as JSON at all, which also means this
+			// jules version doesn't actually support the flag despite
+			// exiting zero.
+			return nil, false, nil
+		}
+		// The flag is supported and the command exited zero, but what came
+		// back isn't the []Session shape we expect -- a genuine parse
+		// failure, not an unsupported flag.
+		return nil, false, errline(newParseError(cmd, output, dur, err))
+	}
+	return sessions, true, nil
+}
+
+// looksLikeJSON is a cheap sniff for whether output is at least
+// structurally JSON, to distinguish "this jules version doesn't support
+// this flag" from "it does, but returned something we don't understand".
+func looksLikeJSON(output []byte) bool {
+	trimmed := strings.TrimSpace(string(output))
+	return strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{")
+}
+
+// flagUnsupported sniffs a failed command's exit code and stderr for the
+// signs of an unrecognized flag, as opposed to a real extraction failure
+// (auth error, network error, etc.) that should be surfaced to the caller.
+func flagUnsupported(output string, err error) bool {
+	lower := strings.ToLower(output)
+	for _, marker := range []string{"unknown flag", "unrecognized flag", "flag provided but not defined", "unknown command", "invalid flag"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	if exitErr, ok := err.(interface{ ExitCode() int }); ok {
+		// jules uses exit code 2 for CLI usage errors (bad flags) and
+		// reserves 1 for operational failures.
+		return exitErr.ExitCode() == 2
+	}
+	return false
+}
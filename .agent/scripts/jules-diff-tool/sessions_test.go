@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// fixtureSessionSource is a SessionSource backed by an in-memory slice, the
+// fixture-injection point sessions.go's SessionSource doc comment promises.
+type fixtureSessionSource struct {
+	sessions []Session
+	err      error
+}
+
+func (f fixtureSessionSource) List(ctx context.Context) ([]Session, error) {
+	return f.sessions, f.err
+}
+
+func TestSessionSourceFixture(t *testing.T) {
+	want := []Session{
+		{ID: "s1", Status: "Completed"},
+		{ID: "s2", Status: "Failed"},
+	}
+
+	var source SessionSource = fixtureSessionSource{sessions: want}
+	got, err := source.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("List returned %d sessions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("session %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFilterSessionsUsesFixture(t *testing.T) {
+	source := fixtureSessionSource{sessions: []Session{
+		{ID: "s1", Status: "Completed"},
+		{ID: "s2", Status: "In Progress"},
+		{ID: "s3", Status: "Failed"},
+	}}
+
+	sessions, err := source.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	filtered := filterSessions(sessions, false, "")
+	if len(filtered) != 1 || filtered[0].ID != "s1" {
+		t.Errorf("filterSessions default filter = %+v, want only s1 (Completed)", filtered)
+	}
+
+	all := filterSessions(sessions, true, "")
+	if len(all) != len(sessions) {
+		t.Errorf("filterSessions(all=true) = %d sessions, want %d", len(all), len(sessions))
+	}
+}
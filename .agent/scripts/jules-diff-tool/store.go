@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest maps the files a session's diff touched to the content-addressed
+// object that holds their bytes, so a session directory can be reassembled
+// from objects/ without re-pulling from `jules`.
+type Manifest struct {
+	ChangesDiff string            `json:"changes_diff"` // hash of the full, filtered diff
+	Hunks       map[string]string `json:"hunks"`        // "a/" path -> hash of that hunk's text
+}
+
+// objectStore is a content-addressed blob store rooted at <output>/objects,
+// shared across every date directory so identical diffs/hunks produced by
+// overlapping sessions are written once.
+type objectStore struct {
+	root string
+}
+
+func newObjectStore(outputDir string) *objectStore {
+	return &objectStore{root: filepath.Join(outputDir, "objects")}
+}
+
+func (s *objectStore) path(hash string) string {
+	return filepath.Join(s.root, hash[:2], hash)
+}
+
+// put hashes data and writes it to the store if not already present,
+// reporting whether this call actually wrote new bytes (a dedup miss) so
+// callers can track physical vs logical bytes saved.
+func (s *objectStore) put(data []byte) (hash string, wroteNew bool, err error) {
+	sum := sha256.Sum256(data)
+	hash = hex.EncodeToString(sum[:])
+	dst := s.path(hash)
+
+	if _, err := os.Stat(dst); err == nil {
+		return hash, false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", false, err
+	}
+
+	// Use a per-call unique temp name (not just dst+".tmp"): two workers
+	// storing identical content race to the same hash, and a shared temp
+	// path means the second rename fails with ENOENT once the first has
+	// already moved it away.
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return "", false, err
+	}
+	tmpPath := tmp.Name()
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return "", false, writeErr
+	}
+	if closeErr != nil {
+		os.Remove(tmpPath)
+		return "", false, closeErr
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		// Another writer may have raced us to the same content-addressed
+		// path in between our Stat and our Rename; if dst exists now,
+		// that's a dedup hit, not a failure.
+		if _, statErr := os.Stat(dst); statErr == nil {
+			return hash, false, nil
+		}
+		return "", false, err
+	}
+	return hash, true, nil
+}
+
+// storeSession content-addresses diff (and its per-file hunks) into the
+// store, writes manifest.json and a changes.diff symlink into sessionDir,
+// and returns the logical/physical byte counts this call stored. (--stats
+// does not use these; it recomputes both totals from the manifests.)
+func (s *objectStore) storeSession(sessionDir string, diff string) (manifest Manifest, logicalBytes, physicalBytes int64, err error) {
+	manifest.Hunks = make(map[string]string)
+
+	hash, wroteNew, err := s.put([]byte(diff))
+	if err != nil {
+		return manifest, 0, 0, fmt.Errorf("storing changes.diff: %w", err)
+	}
+	manifest.ChangesDiff = hash
+	logicalBytes += int64(len(diff))
+	if wroteNew {
+		physicalBytes += int64(len(diff))
+	}
+
+	for _, h := range splitDiffHunks(diff) {
+		hhash, hNew, err := s.put([]byte(h.text))
+		if err != nil {
+			return manifest, 0, 0, fmt.Errorf("storing hunk %s: %w", h.path, err)
+		}
+		manifest.Hunks[h.path] = hhash
+		logicalBytes += int64(len(h.text))
+		if hNew {
+			physicalBytes += int64(len(h.text))
+		}
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, 0, 0, err
+	}
+	if err := os.WriteFile(filepath.Join(sessionDir, "manifest.json"), manifestBytes, 0644); err != nil {
+		return manifest, 0, 0, err
+	}
+
+	diffLink := filepath.Join(sessionDir, "changes.diff")
+	relTarget, err := filepath.Rel(sessionDir, s.path(manifest.ChangesDiff))
+	if err != nil {
+		relTarget = s.path(manifest.ChangesDiff)
+	}
+	os.Remove(diffLink)
+	if err := os.Symlink(relTarget, diffLink); err != nil {
+		// Symlinks aren't available on every filesystem (e.g. some CI
+		// sandboxes); fall back to a plain copy so the output is still
+		// readable.
+		if werr := os.WriteFile(diffLink, []byte(diff), 0644); werr != nil {
+			return manifest, 0, 0, werr
+		}
+	}
+
+	return manifest, logicalBytes, physicalBytes, nil
+}
+
+// State is the persisted (session_id -> extraction record) index used to
+// skip sessions whose LastActive hasn't moved since the last run.
+type State struct {
+	Sessions map[string]StateEntry `json:"sessions"`
+}
+
+type StateEntry struct {
+	LastActive string `json:"last_active"`
+	FilesCount int    `json:"files_count"`
+}
+
+func statePath(outputDir string) string {
+	return filepath.Join(outputDir, "state.json")
+}
+
+func loadState(outputDir string) (*State, error) {
+	data, err := os.ReadFile(statePath(outputDir))
+	if os.IsNotExist(err) {
+		return &State{Sessions: make(map[string]StateEntry)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	if st.Sessions == nil {
+		st.Sessions = make(map[string]StateEntry)
+	}
+	return &st, nil
+}
+
+func (st *State) save(outputDir string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath(outputDir), data, 0644)
+}
+
+// unchanged reports whether session has already been extracted at its
+// current LastActive value.
+func (st *State) unchanged(session Session) (StateEntry, bool) {
+	entry, ok := st.Sessions[session.ID]
+	return entry, ok && entry.LastActive == session.LastActive
+}
+
+// gc walks every manifest.json under outputDir, then deletes any object
+// under objects/ that no manifest references.
+func gc(outputDir string) (removed int, freedBytes int64, err error) {
+	store := newObjectStore(outputDir)
+	referenced := make(map[string]bool)
+
+	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Base(path) != "manifest.json" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil // skip malformed manifests rather than aborting gc
+		}
+		referenced[m.ChangesDiff] = true
+		for _, h := range m.Hunks {
+			referenced[h] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = filepath.Walk(store.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return err
+		}
+		hash := filepath.Base(path)
+		if !referenced[hash] {
+			freedBytes += info.Size()
+			removed++
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return removed, freedBytes, err
+}
+
+// stats walks every manifest.json under outputDir and reports logical bytes
+// (sum of every reference, double-counting shared objects) against physical
+// bytes (the actual size of each distinct object on disk) so the savings
+// from cross-session dedup are visible.
+func stats(outputDir string) (logicalBytes, physicalBytes int64, err error) {
+	store := newObjectStore(outputDir)
+	sizes := make(map[string]int64)
+
+	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Base(path) != "manifest.json" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil
+		}
+
+		for _, hash := range append([]string{m.ChangesDiff}, mapValues(m.Hunks)...) {
+			if hash == "" {
+				continue
+			}
+			size, ok := sizes[hash]
+			if !ok {
+				info, err := os.Stat(store.path(hash))
+				if err != nil {
+					continue
+				}
+				size = info.Size()
+				sizes[hash] = size
+			}
+			logicalBytes += size
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, size := range sizes {
+		physicalBytes += size
+	}
+	return logicalBytes, physicalBytes, nil
+}
+
+func mapValues(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	return out
+}
+
+// humanSize formats n bytes the way bytefmt does, e.g. "1.2 MiB".
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for n/div >= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
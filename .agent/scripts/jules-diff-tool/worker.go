@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// extractionResult is what a worker reports back for a single session, in
+// the shape needed to render its SUMMARY.md row.
+type extractionResult struct {
+	index       int
+	session     Session
+	filesCount  int
+	err         error
+	interrupted bool
+}
+
+// status tracks what each worker is currently doing, for the live
+// multi-line status area.
+type status struct {
+	mu      sync.Mutex
+	working map[int]string // worker index -> session ID
+}
+
+func newStatus() *status {
+	return &status{working: make(map[int]string)}
+}
+
+func (s *status) set(worker int, sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sessionID == "" {
+		delete(s.working, worker)
+	} else {
+		s.working[worker] = sessionID
+	}
+}
+
+func (s *status) lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := make([]string, 0, len(s.working))
+	for worker, sessionID := range s.working {
+		lines = append(lines, fmt.Sprintf("  worker %d: %s", worker, sessionID))
+	}
+	return lines
+}
+
+// progressBar renders "completed/total, throughput, ETA" to stderr on every
+// tick, in the style of a cheggaaa/pb bar, plus the worker status area
+// beneath it. On a terminal it redraws that block in place; piped to a
+// file or CI log (no terminal to move the cursor on), it falls back to one
+// plain summary line per tick instead of emitting raw control codes.
+type progressBar struct {
+	total     int
+	start     time.Time
+	completed int
+	tty       bool
+	lastLines int
+	mu        sync.Mutex
+}
+
+func newProgressBar(total int) *progressBar {
+	return &progressBar{total: total, start: time.Now(), tty: isTerminal(os.Stderr)}
+}
+
+func (p *progressBar) increment() {
+	p.mu.Lock()
+	p.completed++
+	p.mu.Unlock()
+}
+
+// render is only ever called from runExtraction's single select loop, so
+// lastLines needs no locking of its own even though completed/total do.
+func (p *progressBar) render(st *status) {
+	p.mu.Lock()
+	completed, total := p.completed, p.total
+	p.mu.Unlock()
+
+	elapsed := time.Since(p.start)
+	rate := float64(completed) / elapsed.Seconds()
+	var eta time.Duration
+	if rate > 0 {
+		eta = time.Duration(float64(total-completed)/rate) * time.Second
+	}
+	summary := fmt.Sprintf("[%d/%d] %.1f sessions/s, ETA %s", completed, total, rate, eta.Round(time.Second))
+
+	if !p.tty {
+		fmt.Fprintln(os.Stderr, summary)
+		return
+	}
+
+	lines := st.lines()
+	for i := 0; i < p.lastLines; i++ {
+		fmt.Fprint(os.Stderr, "\033[1A\033[2K")
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	for _, line := range lines {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	p.lastLines = 1 + len(lines)
+}
+
+// isTerminal reports whether f is attached to a terminal, without pulling
+// in golang.org/x/term: a char device is as good a signal as this tool
+// needs for "is it safe to move the cursor here".
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// runExtraction pulls diffs for sessions concurrently across jobs workers,
+// writing each session's output as soon as its diff arrives and returning
+// results ordered the same as sessions so the caller can append SUMMARY.md
+// rows in a stable order regardless of completion order. It stops early,
+// marking in-flight sessions INTERRUPTED, if ctx is cancelled.
+func runExtraction(ctx context.Context, sessions []Session, baseDir string, jobs int, sel *SelectFilter, store *objectStore) []extractionResult {
+	jobCh := make(chan int)
+	resultCh := make(chan extractionResult)
+	st := newStatus()
+	bar := newProgressBar(len(sessions))
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range jobCh {
+				session := sessions[i]
+				st.set(worker, session.ID)
+
+				select {
+				case <-ctx.Done():
+					resultCh <- extractionResult{index: i, session: session, interrupted: true}
+					st.set(worker, "")
+					continue
+				default:
+				}
+
+				filesCount, err := extractSession(ctx, session, baseDir, sel, store)
+				interrupted := ctx.Err() != nil && err != nil
+				resultCh <- extractionResult{index: i, session: session, filesCount: filesCount, err: err, interrupted: interrupted}
+				st.set(worker, "")
+			}
+		}(w)
+	}
+
+	go func() {
+		defer close(jobCh)
+		for i := range sessions {
+			select {
+			case jobCh <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	results := make([]extractionResult, len(sessions))
+	seen := make([]bool, len(sessions))
+	remaining := len(sessions)
+
+	for remaining > 0 {
+		select {
+		case r, ok := <-resultCh:
+			if !ok {
+				remaining = 0
+				break
+			}
+			results[r.index] = r
+			seen[r.index] = true
+			remaining--
+			bar.increment()
+		case <-ticker.C:
+			bar.render(st)
+		}
+	}
+	bar.render(st)
+
+	// Any session never handed a result (because the producer stopped
+	// early on cancellation) is reported as interrupted too.
+	for i := range sessions {
+		if !seen[i] {
+			results[i] = extractionResult{index: i, session: sessions[i], interrupted: true}
+		}
+	}
+
+	return results
+}
+
+// installCancelOnSignal cancels the returned context the first time SIGINT
+// or SIGTERM arrives, so in-flight exec.Command calls can terminate
+// gracefully instead of the process dying mid-extraction.
+func installCancelOnSignal(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigCh:
+			fmt.Fprintln(os.Stderr, "\nReceived interrupt, cancelling in-flight sessions...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigCh)
+		cancel()
+	}
+}
+
+// runCommandContext runs name/args under ctx, terminating the process with
+// SIGTERM (rather than the default SIGKILL) when ctx is cancelled, so
+// `jules` has a chance to clean up.
+func runCommandContext(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	return cmd.CombinedOutput()
+}